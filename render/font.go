@@ -0,0 +1,60 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// CP437Font is a Font loaded from a REXPaint-style font sheet: a 16x16 grid of equally sized glyph tiles,
+// indexed by CP437 code point, with magenta (255, 0, 255) marking the transparent parts of each tile. The
+// sheet is sliced into per-glyph alpha masks once, at load time.
+type CP437Font struct {
+	glyphs  [256]*image.Alpha
+	advance image.Point
+}
+
+// LoadCP437Font decodes a font sheet PNG from r and builds a CP437Font from it. Glyph cell size is derived
+// from the sheet's dimensions divided by 16.
+func LoadCP437Font(r io.Reader) (*CP437Font, error) {
+	sheet, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := sheet.Bounds()
+	font := &CP437Font{
+		advance: image.Point{X: bounds.Dx() / 16, Y: bounds.Dy() / 16},
+	}
+
+	for code := range 256 {
+		tileX := bounds.Min.X + (code%16)*font.advance.X
+		tileY := bounds.Min.Y + (code/16)*font.advance.Y
+
+		mask := image.NewAlpha(image.Rect(0, 0, font.advance.X, font.advance.Y))
+		for y := range font.advance.Y {
+			for x := range font.advance.X {
+				r32, g32, b32, _ := sheet.At(tileX+x, tileY+y).RGBA()
+				if r32>>8 == 0xFF && g32>>8 == 0 && b32>>8 == 0xFF {
+					continue //magenta is transparent
+				}
+
+				mask.SetAlpha(x, y, color.Alpha{A: 0xFF})
+			}
+		}
+
+		font.glyphs[code] = mask
+	}
+
+	return font, nil
+}
+
+// Glyph implements reximage.Font.
+func (f *CP437Font) Glyph(code uint32) (mask *image.Alpha, advance image.Point) {
+	if code > 255 {
+		return nil, f.advance
+	}
+
+	return f.glyphs[code], f.advance
+}
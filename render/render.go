@@ -0,0 +1,57 @@
+// Package render rasterizes reximage.ImageData into pixel images by blitting per-cell glyph bitmaps from
+// a bitmap Font, and encodes the result as PNG or BMP.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+
+	"github.com/BenNicholls/reximage"
+)
+
+// RenderPNG rasterizes img by blitting font's per-cell glyph bitmaps and encodes the result as a PNG to w.
+func RenderPNG(w io.Writer, img reximage.ImageData, font reximage.Font) error {
+	return png.Encode(w, rasterize(img, font))
+}
+
+// RenderBMP rasterizes img the same way RenderPNG does, and encodes the result as a BMP to w.
+func RenderBMP(w io.Writer, img reximage.ImageData, font reximage.Font) error {
+	return bmp.Encode(w, rasterize(img, font))
+}
+
+// rasterize blits img's cells onto a pixel canvas in row-major order: each cell rectangle is filled with
+// the cell's background colour, then font's glyph mask for that cell is drawn over it tinted with the
+// foreground colour, using draw.Over. Undrawn cells are skipped entirely.
+func rasterize(img reximage.ImageData, font reximage.Font) *image.RGBA {
+	_, advance := font.Glyph(0)
+	canvas := image.NewRGBA(image.Rect(0, 0, img.Width*advance.X, img.Height*advance.Y))
+
+	for y := range img.Height {
+		for x := range img.Width {
+			cell, _ := img.GetCell(x, y)
+			if cell.Undrawn() {
+				continue
+			}
+
+			cellRect := image.Rect(x*advance.X, y*advance.Y, (x+1)*advance.X, (y+1)*advance.Y)
+
+			back := &image.Uniform{C: color.RGBA{cell.R_b, cell.G_b, cell.B_b, 0xFF}}
+			draw.Draw(canvas, cellRect, back, image.Point{}, draw.Src)
+
+			mask, _ := font.Glyph(cell.Glyph)
+			if mask == nil {
+				continue
+			}
+
+			fore := &image.Uniform{C: color.RGBA{cell.R_f, cell.G_f, cell.B_f, 0xFF}}
+			draw.DrawMask(canvas, cellRect, fore, image.Point{}, mask, image.Point{}, draw.Over)
+		}
+	}
+
+	return canvas
+}
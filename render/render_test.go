@@ -0,0 +1,127 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/BenNicholls/reximage"
+)
+
+// sheet builds a 16x16 pixel synthetic font sheet (one pixel per glyph tile) with every tile magenta
+// (transparent) except the ones listed in opaque, which are set to white.
+func sheet(opaque ...uint32) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for code := range 256 {
+		x, y := code%16, code/16
+		img.Set(x, y, color.RGBA{255, 0, 255, 255})
+	}
+	for _, code := range opaque {
+		x, y := int(code)%16, int(code)/16
+		img.Set(x, y, color.RGBA{255, 255, 255, 255})
+	}
+
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+	return buf
+}
+
+func TestLoadCP437FontMasksMagentaAsTransparent(t *testing.T) {
+	font, err := LoadCP437Font(encodePNG(t, sheet('A')))
+	if err != nil {
+		t.Fatalf("LoadCP437Font returned error: %v", err)
+	}
+
+	if font.advance.X != 1 || font.advance.Y != 1 {
+		t.Fatalf("expected a 1x1 advance for a 16x16 sheet, got %dx%d", font.advance.X, font.advance.Y)
+	}
+
+	mask, _ := font.Glyph('A')
+	if mask.AlphaAt(0, 0).A != 0xFF {
+		t.Errorf("expected glyph 'A's tile to be opaque, got alpha %d", mask.AlphaAt(0, 0).A)
+	}
+
+	mask, _ = font.Glyph('B')
+	if mask.AlphaAt(0, 0).A != 0 {
+		t.Errorf("expected glyph 'B's untouched (magenta) tile to be transparent, got alpha %d", mask.AlphaAt(0, 0).A)
+	}
+}
+
+func TestGlyphOutOfRangeReturnsNilMask(t *testing.T) {
+	font, err := LoadCP437Font(encodePNG(t, sheet()))
+	if err != nil {
+		t.Fatalf("LoadCP437Font returned error: %v", err)
+	}
+
+	mask, advance := font.Glyph(256)
+	if mask != nil {
+		t.Errorf("expected a nil mask for an out-of-range code, got %v", mask)
+	}
+	if advance != font.advance {
+		t.Errorf("expected the out-of-range advance to still match the font's, got %v", advance)
+	}
+}
+
+func TestRenderPNGDrawsForegroundOverBackground(t *testing.T) {
+	font, err := LoadCP437Font(encodePNG(t, sheet('A')))
+	if err != nil {
+		t.Fatalf("LoadCP437Font returned error: %v", err)
+	}
+
+	img := reximage.ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, reximage.CellData{Glyph: 'A', R_f: 10, G_f: 20, B_f: 30, R_b: 200, G_b: 210, B_b: 220})
+
+	buf := new(bytes.Buffer)
+	if err := RenderPNG(buf, img, font); err != nil {
+		t.Fatalf("RenderPNG returned error: %v", err)
+	}
+
+	got, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("png.Decode returned error: %v", err)
+	}
+
+	if b := got.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Fatalf("expected a 1x1 rendered image, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	r, g, b, _ := got.At(0, 0).RGBA()
+	if r>>8 != 10 || g>>8 != 20 || b>>8 != 30 {
+		t.Errorf("expected the opaque glyph pixel to show the foreground colour, got (%d,%d,%d)", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestRenderPNGSkipsUndrawnCells(t *testing.T) {
+	font, err := LoadCP437Font(encodePNG(t, sheet('A')))
+	if err != nil {
+		t.Fatalf("LoadCP437Font returned error: %v", err)
+	}
+
+	img := reximage.ImageData{}
+	img.Init(1, 1) // Init leaves the cell undrawn
+
+	buf := new(bytes.Buffer)
+	if err := RenderPNG(buf, img, font); err != nil {
+		t.Fatalf("RenderPNG returned error: %v", err)
+	}
+
+	got, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("png.Decode returned error: %v", err)
+	}
+
+	r, g, b, a := got.At(0, 0).RGBA()
+	if r != 0 || g != 0 || b != 0 || a != 0 {
+		t.Errorf("expected an undrawn cell to leave its pixel untouched (zero value), got (%d,%d,%d,%d)", r, g, b, a)
+	}
+}
@@ -0,0 +1,291 @@
+package reximage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func cellAt(glyph uint32, fr, fg, fb, br, bg, bb uint8) CellData {
+	return CellData{Glyph: glyph, R_f: fr, G_f: fg, B_f: fb, R_b: br, G_b: bg, B_b: bb}
+}
+
+func TestCompositeOverSkipsUndrawnCells(t *testing.T) {
+	dst := ImageData{}
+	dst.Init(2, 1)
+	dst.SetCell(0, 0, cellAt('A', 1, 1, 1, 2, 2, 2))
+	dst.SetCell(1, 0, cellAt('B', 1, 1, 1, 2, 2, 2))
+
+	src := ImageData{}
+	src.Init(2, 1)
+	src.SetCell(0, 0, cellAt('C', 9, 9, 9, 8, 8, 8)) // drawn, should overwrite
+	// leave src's second cell undrawn (its default state after Init)
+
+	Composite(&dst, src, 0, 0, CompositeOver)
+
+	got, _ := dst.GetCell(0, 0)
+	if got.Glyph != 'C' {
+		t.Errorf("cell (0,0): expected drawn source cell to overwrite destination, got glyph %q", got.Glyph)
+	}
+
+	got, _ = dst.GetCell(1, 0)
+	if got.Glyph != 'B' {
+		t.Errorf("cell (1,0): expected undrawn source cell to leave destination untouched, got glyph %q", got.Glyph)
+	}
+}
+
+func TestCompositeSrcOverwritesUndrawnCells(t *testing.T) {
+	dst := ImageData{}
+	dst.Init(1, 1)
+	dst.SetCell(0, 0, cellAt('A', 1, 1, 1, 2, 2, 2))
+
+	src := ImageData{} // Init leaves every cell undrawn
+	src.Init(1, 1)
+
+	Composite(&dst, src, 0, 0, CompositeSrc)
+
+	got, _ := dst.GetCell(0, 0)
+	if !got.Undrawn() {
+		t.Errorf("expected CompositeSrc to overwrite with the undrawn source cell, got %+v", got)
+	}
+}
+
+func TestImageFlatten(t *testing.T) {
+	img := Image{Width: 2, Height: 1}
+
+	bottom := Layer{Name: "bottom", Visible: true}
+	bottom.Init(2, 1)
+	bottom.SetCell(0, 0, cellAt('X', 1, 1, 1, 2, 2, 2))
+	bottom.SetCell(1, 0, cellAt('Y', 1, 1, 1, 2, 2, 2))
+
+	top := Layer{Name: "top", Visible: true}
+	top.Init(2, 1)
+	top.SetCell(0, 0, cellAt('Z', 3, 3, 3, 4, 4, 4)) // drawn, should win
+	// top's cell (1,0) stays undrawn, bottom should show through
+
+	img.Layers = []Layer{bottom, top}
+
+	flat := img.Flatten()
+
+	got, _ := flat.GetCell(0, 0)
+	if got.Glyph != 'Z' {
+		t.Errorf("cell (0,0): expected top layer's drawn cell, got glyph %q", got.Glyph)
+	}
+
+	got, _ = flat.GetCell(1, 0)
+	if got.Glyph != 'Y' {
+		t.Errorf("cell (1,0): expected bottom layer to show through top's undrawn cell, got glyph %q", got.Glyph)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	img := ImageData{}
+	img.Init(2, 2)
+	img.SetCell(0, 0, cellAt('H', 10, 20, 30, 40, 50, 60))
+	img.SetCell(1, 1, cellAt('i', 70, 80, 90, 100, 110, 120))
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, img); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	got, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+
+	if got.Width != img.Width || got.Height != img.Height {
+		t.Fatalf("dimensions changed across round trip: got %dx%d, want %dx%d", got.Width, got.Height, img.Width, img.Height)
+	}
+
+	for i, want := range img.Cells {
+		if got.Cells[i] != want {
+			t.Errorf("cell %d: got %+v, want %+v", i, got.Cells[i], want)
+		}
+	}
+}
+
+func TestNewReaderNewWriterRoundTrip(t *testing.T) {
+	layer := Layer{Name: "Layer 0", Visible: true}
+	layer.Init(1, 1)
+	layer.SetCell(0, 0, cellAt('@', 1, 2, 3, 4, 5, 6))
+
+	img := Image{Width: 1, Height: 1, Layers: []Layer{layer}}
+
+	buf := new(bytes.Buffer)
+	if err := NewWriter(buf, img); err != nil {
+		t.Fatalf("NewWriter returned error: %v", err)
+	}
+
+	got, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+
+	if len(got.Layers) != 1 {
+		t.Fatalf("expected 1 layer back, got %d", len(got.Layers))
+	}
+
+	gotCell, _ := got.Layers[0].GetCell(0, 0)
+	wantCell, _ := layer.GetCell(0, 0)
+	if gotCell != wantCell {
+		t.Errorf("got cell %+v, want %+v", gotCell, wantCell)
+	}
+}
+
+func TestQuantizeSnapsToNearestPaletteEntry(t *testing.T) {
+	img := ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('#', 10, 10, 10, 200, 200, 200))
+
+	palette := color.Palette{
+		color.RGBA{0, 0, 0, 0xFF},
+		color.RGBA{255, 255, 255, 0xFF},
+	}
+
+	got := img.Quantize(palette)
+
+	cell, _ := got.GetCell(0, 0)
+	if cell.R_f != 0 || cell.G_f != 0 || cell.B_f != 0 {
+		t.Errorf("expected foreground to snap to black, got (%d,%d,%d)", cell.R_f, cell.G_f, cell.B_f)
+	}
+	if cell.R_b != 255 || cell.G_b != 255 || cell.B_b != 255 {
+		t.Errorf("expected background to snap to white, got (%d,%d,%d)", cell.R_b, cell.G_b, cell.B_b)
+	}
+}
+
+func TestQuantizeWithEmptyPaletteIsANoop(t *testing.T) {
+	img := ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('#', 10, 20, 30, 40, 50, 60))
+
+	got := img.Quantize(color.Palette{})
+
+	cell, _ := got.GetCell(0, 0)
+	want, _ := img.GetCell(0, 0)
+	if cell != want {
+		t.Errorf("expected an empty palette to leave cells untouched, got %+v, want %+v", cell, want)
+	}
+}
+
+func TestExportImportIndexedRoundTrip(t *testing.T) {
+	img := ImageData{}
+	img.Init(2, 1)
+	img.SetCell(0, 0, cellAt('A', 0, 0, 0, 255, 255, 255))
+	img.SetCell(1, 0, cellAt('B', 255, 255, 255, 0, 0, 0))
+
+	palette := img.Palette()
+
+	buf := new(bytes.Buffer)
+	if err := ExportIndexed(buf, img, palette); err != nil {
+		t.Fatalf("ExportIndexed returned error: %v", err)
+	}
+
+	got, err := ImportIndexed(buf)
+	if err != nil {
+		t.Fatalf("ImportIndexed returned error: %v", err)
+	}
+
+	for i, want := range img.Cells {
+		if got.Cells[i] != want {
+			t.Errorf("cell %d: got %+v, want %+v", i, got.Cells[i], want)
+		}
+	}
+}
+
+func TestExportIndexedRejectsEmptyPalette(t *testing.T) {
+	img := ImageData{}
+	img.Init(1, 1)
+
+	if err := ExportIndexed(new(bytes.Buffer), img, color.Palette{}); err == nil {
+		t.Error("expected ExportIndexed to return an error for an empty palette, got nil")
+	}
+}
+
+// fakeFont is a minimal Font for testing RenderedImage's glyph mode: it returns a fixed advance and mask
+// for every glyph code, regardless of what's asked for.
+type fakeFont struct {
+	advance image.Point
+	mask    *image.Alpha
+}
+
+func (f fakeFont) Glyph(code uint32) (*image.Alpha, image.Point) {
+	return f.mask, f.advance
+}
+
+func TestRenderedImageBackgroundMode(t *testing.T) {
+	img := ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('A', 1, 2, 3, 4, 5, 6))
+
+	r := RenderedImage{ImageData: img, Mode: RenderBackground}
+
+	if b := r.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Fatalf("expected a 1x1 bounds, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	rr, gg, bb, _ := r.At(0, 0).RGBA()
+	if rr>>8 != 4 || gg>>8 != 5 || bb>>8 != 6 {
+		t.Errorf("expected the background colour, got (%d,%d,%d)", rr>>8, gg>>8, bb>>8)
+	}
+}
+
+func TestRenderedImageForegroundMode(t *testing.T) {
+	img := ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('A', 1, 2, 3, 4, 5, 6))
+
+	r := RenderedImage{ImageData: img, Mode: RenderForeground}
+
+	rr, gg, bb, _ := r.At(0, 0).RGBA()
+	if rr>>8 != 1 || gg>>8 != 2 || bb>>8 != 3 {
+		t.Errorf("expected the foreground colour, got (%d,%d,%d)", rr>>8, gg>>8, bb>>8)
+	}
+}
+
+func TestRenderedImageGlyphMode(t *testing.T) {
+	mask := image.NewAlpha(image.Rect(0, 0, 2, 2))
+	mask.SetAlpha(0, 0, color.Alpha{A: 0xFF}) // only the top-left pixel of the glyph is opaque
+
+	font := fakeFont{advance: image.Point{X: 2, Y: 2}, mask: mask}
+
+	img := ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('A', 1, 2, 3, 4, 5, 6))
+
+	r := RenderedImage{ImageData: img, Mode: RenderGlyph, Font: font}
+
+	if b := r.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("expected a 2x2 bounds (1 cell x 2x2 advance), got %dx%d", b.Dx(), b.Dy())
+	}
+
+	rr, gg, bb, _ := r.At(0, 0).RGBA()
+	if rr>>8 != 1 || gg>>8 != 2 || bb>>8 != 3 {
+		t.Errorf("expected the opaque glyph pixel to show the foreground colour, got (%d,%d,%d)", rr>>8, gg>>8, bb>>8)
+	}
+
+	rr, gg, bb, _ = r.At(1, 1).RGBA()
+	if rr>>8 != 4 || gg>>8 != 5 || bb>>8 != 6 {
+		t.Errorf("expected the transparent glyph pixel to show the background colour, got (%d,%d,%d)", rr>>8, gg>>8, bb>>8)
+	}
+}
+
+func TestRenderedImageGlyphModeSkipsUndrawnCells(t *testing.T) {
+	mask := image.NewAlpha(image.Rect(0, 0, 1, 1))
+	mask.SetAlpha(0, 0, color.Alpha{A: 0xFF})
+
+	font := fakeFont{advance: image.Point{X: 1, Y: 1}, mask: mask}
+
+	img := ImageData{}
+	img.Init(1, 1) // Init leaves the cell undrawn
+
+	r := RenderedImage{ImageData: img, Mode: RenderGlyph, Font: font}
+
+	cell, _ := img.GetCell(0, 0)
+	want := color.RGBA{cell.R_b, cell.G_b, cell.B_b, 0xFF}
+
+	if got := r.At(0, 0); got != want {
+		t.Errorf("expected an undrawn cell to show its background colour regardless of the glyph mask, got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+// Package transform provides geometric operations on reximage.ImageData: flips, rotations, cropping,
+// pasting, and tiling, in the spirit of disintegration/imaging's API.
+package transform
+
+import (
+	"image"
+
+	"github.com/BenNicholls/reximage"
+)
+
+// GlyphRotationTable maps a glyph to the glyph it becomes after rotation. Index 0 is the glyph's own
+// (unrotated) code, 1 its 90° clockwise orientation, 2 its 180° orientation, and 3 its 270° clockwise
+// orientation, e.g. table['─'] = [4]uint32{'─', '│', '─', '│'}. A nil table, or a glyph missing from it,
+// leaves that cell's glyph untouched by Rotate90/Rotate180/Rotate270/Transpose.
+type GlyphRotationTable map[uint32][4]uint32
+
+func rotateGlyph(table GlyphRotationTable, glyph uint32, steps int) uint32 {
+	if table == nil {
+		return glyph
+	}
+
+	rotations, ok := table[glyph]
+	if !ok {
+		return glyph
+	}
+
+	return rotations[steps]
+}
+
+// FlipH mirrors img horizontally, left to right.
+func FlipH(img reximage.ImageData) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Width, img.Height)
+
+	for y := range img.Height {
+		for x := range img.Width {
+			cell, _ := img.GetCell(img.Width-1-x, y)
+			dst.SetCell(x, y, cell)
+		}
+	}
+
+	return dst
+}
+
+// FlipV mirrors img vertically, top to bottom.
+func FlipV(img reximage.ImageData) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Width, img.Height)
+
+	for y := range img.Height {
+		for x := range img.Width {
+			cell, _ := img.GetCell(x, img.Height-1-y)
+			dst.SetCell(x, y, cell)
+		}
+	}
+
+	return dst
+}
+
+// Transpose flips img across its top-left/bottom-right diagonal, swapping rows and columns. Glyphs
+// present in table are rotated to their 90° orientation, the closest match for a diagonal flip.
+func Transpose(img reximage.ImageData, table GlyphRotationTable) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Height, img.Width)
+
+	for y := range img.Height {
+		for x := range img.Width {
+			cell, _ := img.GetCell(x, y)
+			cell.Glyph = rotateGlyph(table, cell.Glyph, 1)
+			dst.SetCell(y, x, cell)
+		}
+	}
+
+	return dst
+}
+
+// Rotate90 rotates img 90° clockwise. Glyphs present in table are rotated to their 90° orientation; glyphs
+// absent from it (or if table is nil) keep their original code.
+func Rotate90(img reximage.ImageData, table GlyphRotationTable) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Height, img.Width)
+
+	for j := range dst.Height {
+		for i := range dst.Width {
+			cell, _ := img.GetCell(j, img.Height-1-i)
+			cell.Glyph = rotateGlyph(table, cell.Glyph, 1)
+			dst.SetCell(i, j, cell)
+		}
+	}
+
+	return dst
+}
+
+// Rotate180 rotates img 180°. Glyphs present in table are rotated to their 180° orientation; glyphs
+// absent from it (or if table is nil) keep their original code.
+func Rotate180(img reximage.ImageData, table GlyphRotationTable) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Width, img.Height)
+
+	for y := range img.Height {
+		for x := range img.Width {
+			cell, _ := img.GetCell(img.Width-1-x, img.Height-1-y)
+			cell.Glyph = rotateGlyph(table, cell.Glyph, 2)
+			dst.SetCell(x, y, cell)
+		}
+	}
+
+	return dst
+}
+
+// Rotate270 rotates img 270° clockwise (90° counter-clockwise). Glyphs present in table are rotated to
+// their 270° orientation; glyphs absent from it (or if table is nil) keep their original code.
+func Rotate270(img reximage.ImageData, table GlyphRotationTable) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(img.Height, img.Width)
+
+	for j := range dst.Height {
+		for i := range dst.Width {
+			cell, _ := img.GetCell(img.Width-1-j, i)
+			cell.Glyph = rotateGlyph(table, cell.Glyph, 3)
+			dst.SetCell(i, j, cell)
+		}
+	}
+
+	return dst
+}
+
+// Crop returns the portion of img within r, clipped to img's bounds, as a new ImageData with its own
+// freshly allocated Cells slice so the result can be mutated independently of img.
+func Crop(img reximage.ImageData, r image.Rectangle) reximage.ImageData {
+	r = r.Intersect(image.Rect(0, 0, img.Width, img.Height))
+
+	dst := reximage.ImageData{}
+	dst.Init(r.Dx(), r.Dy())
+
+	for y := range dst.Height {
+		for x := range dst.Width {
+			cell, _ := img.GetCell(r.Min.X+x, r.Min.Y+y)
+			dst.SetCell(x, y, cell)
+		}
+	}
+
+	return dst
+}
+
+// Paste draws src onto dst at offset (dx, dy), overwriting every covered cell, undrawn ones included. It
+// is a thin wrapper over reximage.Composite using reximage.CompositeSrc.
+func Paste(dst *reximage.ImageData, src reximage.ImageData, dx, dy int) {
+	reximage.Composite(dst, src, dx, dy, reximage.CompositeSrc)
+}
+
+// Tile returns a new w x h ImageData filled by repeating src starting from its top-left corner. Tiles
+// that would overhang the right or bottom edge are clipped. If src has no width or height (e.g. the
+// result of cropping to an empty rectangle), there is nothing to repeat, so Tile returns the w x h dst
+// with every cell left undrawn.
+func Tile(src reximage.ImageData, w, h int) reximage.ImageData {
+	dst := reximage.ImageData{}
+	dst.Init(w, h)
+
+	if src.Width <= 0 || src.Height <= 0 {
+		return dst
+	}
+
+	for y := 0; y < h; y += src.Height {
+		for x := 0; x < w; x += src.Width {
+			Paste(&dst, src, x, y)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,193 @@
+package transform
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/BenNicholls/reximage"
+)
+
+func cellAt(glyph uint32) reximage.CellData {
+	cd := reximage.CellData{}
+	cd.SetColoursRGBA(0xFFFFFFFF, 0xFFFFFFFF) // drawn, arbitrary colours
+	cd.Glyph = glyph
+	return cd
+}
+
+// asGrid reads img row-major into a slice of glyphs, for easy comparison against an expected layout.
+func asGrid(img reximage.ImageData) []uint32 {
+	glyphs := make([]uint32, 0, img.Width*img.Height)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			cell, _ := img.GetCell(x, y)
+			glyphs = append(glyphs, cell.Glyph)
+		}
+	}
+	return glyphs
+}
+
+// rowMajor builds a W x H ImageData whose cells carry the given glyphs in row-major order.
+func rowMajor(w, h int, glyphs ...uint32) reximage.ImageData {
+	img := reximage.ImageData{}
+	img.Init(w, h)
+	for i, g := range glyphs {
+		img.SetCell(i%w, i/w, cellAt(g))
+	}
+	return img
+}
+
+func TestFlipH(t *testing.T) {
+	img := rowMajor(2, 1, 'A', 'B')
+
+	got := asGrid(FlipH(img))
+	want := []uint32{'B', 'A'}
+	if !equalGlyphs(got, want) {
+		t.Errorf("FlipH: got %v, want %v", got, want)
+	}
+}
+
+func TestFlipV(t *testing.T) {
+	img := rowMajor(1, 2, 'A', 'B')
+
+	got := asGrid(FlipV(img))
+	want := []uint32{'B', 'A'}
+	if !equalGlyphs(got, want) {
+		t.Errorf("FlipV: got %v, want %v", got, want)
+	}
+}
+
+func TestRotate90(t *testing.T) {
+	// 2 wide x 1 tall: "A B" rotated 90 clockwise becomes 1 wide x 2 tall, A on top.
+	img := rowMajor(2, 1, 'A', 'B')
+
+	got := Rotate90(img, nil)
+	if got.Width != 1 || got.Height != 2 {
+		t.Fatalf("Rotate90: expected 1x2 result, got %dx%d", got.Width, got.Height)
+	}
+
+	want := []uint32{'A', 'B'}
+	if glyphs := asGrid(got); !equalGlyphs(glyphs, want) {
+		t.Errorf("Rotate90: got %v, want %v", glyphs, want)
+	}
+}
+
+func TestRotate90WithGlyphRotationTable(t *testing.T) {
+	table := GlyphRotationTable{
+		'─': {'─', '│', '─', '│'},
+	}
+
+	img := reximage.ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, cellAt('─'))
+
+	got := Rotate90(img, table)
+
+	cell, _ := got.GetCell(0, 0)
+	if cell.Glyph != '│' {
+		t.Errorf("expected '─' to rotate to '│', got %q", cell.Glyph)
+	}
+}
+
+func TestRotate180TwiceIsIdentity(t *testing.T) {
+	img := rowMajor(2, 2, 'A', 'B', 'C', 'D')
+
+	got := Rotate180(Rotate180(img, nil), nil)
+	if glyphs, want := asGrid(got), asGrid(img); !equalGlyphs(glyphs, want) {
+		t.Errorf("Rotate180 twice: got %v, want %v", glyphs, want)
+	}
+}
+
+func TestRotate90ThriceEqualsRotate270(t *testing.T) {
+	img := rowMajor(2, 3, 'A', 'B', 'C', 'D', 'E', 'F')
+
+	viaThrice := Rotate90(Rotate90(Rotate90(img, nil), nil), nil)
+	direct := Rotate270(img, nil)
+
+	if viaThrice.Width != direct.Width || viaThrice.Height != direct.Height {
+		t.Fatalf("dimension mismatch: %dx%d vs %dx%d", viaThrice.Width, viaThrice.Height, direct.Width, direct.Height)
+	}
+
+	if a, b := asGrid(viaThrice), asGrid(direct); !equalGlyphs(a, b) {
+		t.Errorf("Rotate90 x3: got %v, want %v (Rotate270)", a, b)
+	}
+}
+
+func TestCropReturnsIndependentCells(t *testing.T) {
+	img := rowMajor(2, 2, 'A', 'B', 'C', 'D')
+
+	cropped := Crop(img, image.Rect(0, 0, 1, 1))
+	cropped.SetCell(0, 0, cellAt('Z'))
+
+	orig, _ := img.GetCell(0, 0)
+	if orig.Glyph != 'A' {
+		t.Errorf("mutating the cropped result changed the source image: got glyph %q", orig.Glyph)
+	}
+}
+
+func TestCropOfNonIntersectingRectIsEmpty(t *testing.T) {
+	img := rowMajor(2, 2, 'A', 'B', 'C', 'D')
+
+	cropped := Crop(img, image.Rect(10, 10, 20, 20))
+	if cropped.Width != 0 || cropped.Height != 0 {
+		t.Errorf("expected an empty result, got %dx%d", cropped.Width, cropped.Height)
+	}
+}
+
+func TestPaste(t *testing.T) {
+	dst := rowMajor(2, 1, 'A', 'B')
+	src := rowMajor(1, 1, 'X')
+
+	Paste(&dst, src, 1, 0)
+
+	if got := asGrid(dst); !equalGlyphs(got, []uint32{'A', 'X'}) {
+		t.Errorf("Paste: got %v, want [A X]", got)
+	}
+}
+
+func TestTile(t *testing.T) {
+	src := rowMajor(1, 1, 'A')
+
+	got := Tile(src, 3, 2)
+	if got.Width != 3 || got.Height != 2 {
+		t.Fatalf("expected a 3x2 result, got %dx%d", got.Width, got.Height)
+	}
+
+	for i, g := range asGrid(got) {
+		if g != 'A' {
+			t.Errorf("cell %d: expected tiled glyph 'A', got %q", i, g)
+		}
+	}
+}
+
+// TestTileWithEmptySrcDoesNotHang guards against the infinite loop that a zero-sized src (e.g. from
+// Crop-ing an empty rectangle) used to cause in Tile's step loops.
+func TestTileWithEmptySrcDoesNotHang(t *testing.T) {
+	empty := Crop(rowMajor(2, 2, 'A', 'B', 'C', 'D'), image.Rect(10, 10, 20, 20))
+
+	done := make(chan reximage.ImageData, 1)
+	go func() {
+		done <- Tile(empty, 4, 4)
+	}()
+
+	select {
+	case got := <-done:
+		if got.Width != 4 || got.Height != 4 {
+			t.Errorf("expected a 4x4 result, got %dx%d", got.Width, got.Height)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Tile with a zero-sized src did not return, it hung")
+	}
+}
+
+func equalGlyphs(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
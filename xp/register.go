@@ -0,0 +1,30 @@
+// Package xp registers reximage's Decode and DecodeConfig with the standard image package under the "xp"
+// format name, so image.Decode can sniff and load REXPaint .xp files alongside png, gif, jpeg and the
+// like. It exists as a separate package so that importing reximage itself doesn't drag the image package
+// registry in for callers who don't want it. Import it for its side effect only:
+//
+//	import _ "github.com/BenNicholls/reximage/xp"
+package xp
+
+import (
+	"image"
+	"io"
+
+	"github.com/BenNicholls/reximage"
+)
+
+func init() {
+	// .xp files are gzip streams, so the gzip magic bytes double as the sniff prefix.
+	image.RegisterFormat("xp", "\x1f\x8b", decode, reximage.DecodeConfig)
+}
+
+// decode adapts reximage.Decode to the func(io.Reader) (image.Image, error) signature
+// image.RegisterFormat requires.
+func decode(r io.Reader) (image.Image, error) {
+	img, err := reximage.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return reximage.RenderedImage{ImageData: img}, nil
+}
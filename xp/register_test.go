@@ -0,0 +1,57 @@
+package xp
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/BenNicholls/reximage"
+)
+
+// importing this package (for its side effect) is what registers the "xp" format with image.Decode; this
+// test file lives in package xp, so its init has already run and no blank import is needed here.
+
+func TestImageDecodeRecognisesXPFormat(t *testing.T) {
+	img := reximage.ImageData{}
+	img.Init(1, 1)
+	img.SetCell(0, 0, reximage.CellData{Glyph: 'A', R_f: 1, G_f: 2, B_f: 3, R_b: 4, G_b: 5, B_b: 6})
+
+	buf := new(bytes.Buffer)
+	if err := reximage.Encode(buf, img); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	got, format, err := image.Decode(buf)
+	if err != nil {
+		t.Fatalf("image.Decode returned error: %v", err)
+	}
+	if format != "xp" {
+		t.Errorf("expected format %q, got %q", "xp", format)
+	}
+
+	rr, gg, bb, _ := got.At(0, 0).RGBA()
+	if rr>>8 != 4 || gg>>8 != 5 || bb>>8 != 6 {
+		t.Errorf("expected the decoded image's default RenderBackground mode to show (4,5,6), got (%d,%d,%d)", rr>>8, gg>>8, bb>>8)
+	}
+}
+
+func TestImageDecodeConfigRecognisesXPFormat(t *testing.T) {
+	img := reximage.ImageData{}
+	img.Init(3, 2)
+
+	buf := new(bytes.Buffer)
+	if err := reximage.Encode(buf, img); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	cfg, format, err := image.DecodeConfig(buf)
+	if err != nil {
+		t.Fatalf("image.DecodeConfig returned error: %v", err)
+	}
+	if format != "xp" {
+		t.Errorf("expected format %q, got %q", "xp", format)
+	}
+	if cfg.Width != 3 || cfg.Height != 2 {
+		t.Errorf("expected a 3x2 config, got %dx%d", cfg.Width, cfg.Height)
+	}
+}